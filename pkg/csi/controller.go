@@ -0,0 +1,197 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csi
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/oracle/oci-go-sdk/identity"
+
+	"go.uber.org/zap"
+
+	"github.com/kubernetes-incubator/external-storage/lib/controller"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/oracle/oci-volume-provisioner/pkg/provisioner/block"
+	"github.com/oracle/oci-volume-provisioner/pkg/provisioner/plugin"
+)
+
+// topologyZoneKey is the well-known CSI topology key OCI availability
+// domains are published under; it is the same key block.Provision uses to
+// label FlexVolume PVs, so either driver mode schedules the same way.
+const topologyZoneKey = block.TopologyZoneKey
+
+const defaultVolumeSizeBytes = 50 * 1024 * 1024 * 1024 // 50GiB, matches the OCI default minimum.
+
+type controllerServer struct {
+	logger      *zap.SugaredLogger
+	provisioner plugin.ProvisionerPlugin
+}
+
+func newControllerServer(logger *zap.SugaredLogger, provisioner plugin.ProvisionerPlugin) *controllerServer {
+	return &controllerServer{logger: logger, provisioner: provisioner}
+}
+
+// adFromTopology resolves the availability domain a volume should be
+// created in from the CSI CreateVolumeRequest's accessibility_requirements,
+// taking the first segment that carries topologyZoneKey, searching the
+// preferred segments before the requisite ones.
+func adFromTopology(requirements *csi.TopologyRequirement) (*identity.AvailabilityDomain, error) {
+	if requirements == nil {
+		return nil, status.Error(codes.InvalidArgument, "accessibility_requirements is required to select an availability domain")
+	}
+	for _, topology := range append(requirements.GetPreferred(), requirements.GetRequisite()...) {
+		if zone, ok := topology.GetSegments()[topologyZoneKey]; ok && zone != "" {
+			return &identity.AvailabilityDomain{Name: &zone}, nil
+		}
+	}
+	return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("no accessibility requirement carried the %q topology key", topologyZoneKey))
+}
+
+func (cs *controllerServer) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, error) {
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+
+	ad, err := adFromTopology(req.GetAccessibilityRequirements())
+	if err != nil {
+		return nil, err
+	}
+
+	sizeBytes := int64(defaultVolumeSizeBytes)
+	if req.GetCapacityRange() != nil && req.GetCapacityRange().GetRequiredBytes() > 0 {
+		sizeBytes = req.GetCapacityRange().GetRequiredBytes()
+	}
+
+	options := controller.VolumeOptions{
+		PVName:     req.GetName(),
+		Parameters: req.GetParameters(),
+		PVC: &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: req.GetName()},
+			Spec: v1.PersistentVolumeClaimSpec{
+				AccessModes: []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce},
+				Resources: v1.ResourceRequirements{
+					Requests: v1.ResourceList{
+						v1.ResourceStorage: *resource.NewQuantity(sizeBytes, resource.BinarySI),
+					},
+				},
+			},
+		},
+	}
+
+	pv, err := cs.provisioner.Provision(options, ad)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	volumeID := pv.Annotations[block.OCIVolumeID]
+	return &csi.CreateVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:      volumeID,
+			CapacityBytes: sizeBytes,
+			AccessibleTopology: []*csi.Topology{
+				{Segments: map[string]string{topologyZoneKey: *ad.Name}},
+			},
+		},
+	}, nil
+}
+
+func (cs *controllerServer) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (*csi.DeleteVolumeResponse, error) {
+	if req.GetVolumeId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "volume_id is required")
+	}
+
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{block.OCIVolumeID: req.GetVolumeId()},
+		},
+	}
+	if err := cs.provisioner.Delete(pv); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &csi.DeleteVolumeResponse{}, nil
+}
+
+func (cs *controllerServer) ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (*csi.ControllerPublishVolumeResponse, error) {
+	// The volume is not attached to the node here: the request is only
+	// acknowledged, and the returned publish context is empty, so it
+	// carries no devicePath for NodeStageVolume.
+	return &csi.ControllerPublishVolumeResponse{}, nil
+}
+
+func (cs *controllerServer) ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (*csi.ControllerUnpublishVolumeResponse, error) {
+	return &csi.ControllerUnpublishVolumeResponse{}, nil
+}
+
+func (cs *controllerServer) ValidateVolumeCapabilities(ctx context.Context, req *csi.ValidateVolumeCapabilitiesRequest) (*csi.ValidateVolumeCapabilitiesResponse, error) {
+	for _, capability := range req.GetVolumeCapabilities() {
+		if capability.GetAccessMode().GetMode() != csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER {
+			return &csi.ValidateVolumeCapabilitiesResponse{Message: "only SINGLE_NODE_WRITER is supported"}, nil
+		}
+	}
+	return &csi.ValidateVolumeCapabilitiesResponse{
+		Confirmed: &csi.ValidateVolumeCapabilitiesResponse_Confirmed{
+			VolumeCapabilities: req.GetVolumeCapabilities(),
+		},
+	}, nil
+}
+
+func (cs *controllerServer) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (*csi.ListVolumesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+func (cs *controllerServer) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (*csi.GetCapacityResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+func (cs *controllerServer) ControllerGetCapabilities(ctx context.Context, req *csi.ControllerGetCapabilitiesRequest) (*csi.ControllerGetCapabilitiesResponse, error) {
+	capabilityType := func(t csi.ControllerServiceCapability_RPC_Type) *csi.ControllerServiceCapability {
+		return &csi.ControllerServiceCapability{
+			Type: &csi.ControllerServiceCapability_Rpc{
+				Rpc: &csi.ControllerServiceCapability_RPC{Type: t},
+			},
+		}
+	}
+	return &csi.ControllerGetCapabilitiesResponse{
+		Capabilities: []*csi.ControllerServiceCapability{
+			capabilityType(csi.ControllerServiceCapability_RPC_CREATE_DELETE_VOLUME),
+			capabilityType(csi.ControllerServiceCapability_RPC_PUBLISH_UNPUBLISH_VOLUME),
+		},
+	}, nil
+}
+
+func (cs *controllerServer) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshotRequest) (*csi.CreateSnapshotResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+func (cs *controllerServer) DeleteSnapshot(ctx context.Context, req *csi.DeleteSnapshotRequest) (*csi.DeleteSnapshotResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+func (cs *controllerServer) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRequest) (*csi.ListSnapshotsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+func (cs *controllerServer) ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
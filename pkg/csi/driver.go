@@ -0,0 +1,76 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package csi implements the CSI Identity, Controller and Node services for
+// the OCI volume provisioner, wrapping the same OCI Block Volume logic used
+// by the FlexVolume provisioner in pkg/provisioner/block.
+package csi
+
+import (
+	"net"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc"
+
+	"go.uber.org/zap"
+
+	"github.com/oracle/oci-volume-provisioner/pkg/oci/instancemeta"
+	"github.com/oracle/oci-volume-provisioner/pkg/provisioner/block"
+	"github.com/oracle/oci-volume-provisioner/pkg/provisioner/plugin"
+)
+
+// Driver is the gRPC server implementing the CSI Identity, Controller and
+// Node services on top of a plugin.ProvisionerPlugin.
+type Driver struct {
+	endpoint string
+	version  string
+
+	identity   *identityServer
+	controller *controllerServer
+	node       *nodeServer
+
+	logger *zap.SugaredLogger
+}
+
+// NewDriver creates a new CSI driver instance. The supplied provisioner is
+// the same plugin.ProvisionerPlugin used by the FlexVolume code path, so
+// volumes created through either path are indistinguishable to OCI. metadata
+// lets the node service report the local instance's availability domain from
+// NodeGetInfo.
+func NewDriver(logger *zap.SugaredLogger, endpoint, version string, provisioner plugin.ProvisionerPlugin, metadata instancemeta.Interface) *Driver {
+	return &Driver{
+		endpoint:   endpoint,
+		version:    version,
+		identity:   newIdentityServer(version),
+		controller: newControllerServer(logger, provisioner),
+		node:       newNodeServer(logger, metadata),
+		logger:     logger,
+	}
+}
+
+// Run starts serving the CSI gRPC endpoint and blocks until it stops.
+func (d *Driver) Run() error {
+	listener, err := net.Listen("unix", d.endpoint)
+	if err != nil {
+		return err
+	}
+
+	server := grpc.NewServer()
+	csi.RegisterIdentityServer(server, d.identity)
+	csi.RegisterControllerServer(server, d.controller)
+	csi.RegisterNodeServer(server, d.node)
+
+	d.logger.With("endpoint", d.endpoint).Info("Starting CSI driver")
+	return server.Serve(listener)
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package csi
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"go.uber.org/zap"
+
+	"github.com/oracle/oci-volume-provisioner/pkg/oci/instancemeta"
+	"github.com/oracle/oci-volume-provisioner/pkg/provisioner/block"
+)
+
+type nodeServer struct {
+	logger   *zap.SugaredLogger
+	metadata instancemeta.Interface
+}
+
+func newNodeServer(logger *zap.SugaredLogger, metadata instancemeta.Interface) *nodeServer {
+	return &nodeServer{logger: logger, metadata: metadata}
+}
+
+// deviceFilesystemType returns the filesystem type blkid detects on
+// devicePath, or "" if the device carries no recognizable filesystem.
+func deviceFilesystemType(devicePath string) (string, error) {
+	output, err := exec.Command("blkid", "-o", "value", "-s", "TYPE", devicePath).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 2 {
+			// blkid exits 2 when the device has no recognizable filesystem or
+			// partition signature at all.
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// isMounted reports whether targetPath already appears as a mount point in
+// /proc/mounts.
+func isMounted(targetPath string) (bool, error) {
+	data, err := ioutil.ReadFile("/proc/mounts")
+	if err != nil {
+		return false, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) > 1 && fields[1] == targetPath {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// NodeStageVolume formats the OCI iSCSI/paravirtualized block device, if it
+// isn't already formatted, and mounts it at the plugin's private staging
+// path, mirroring the FSType handling in block.Provision. Both steps are
+// skipped if already done, so re-staging after a pod reschedule or node
+// reboot — and staging a volume restored from an OCI backup via
+// block.Provision's restore path — never reformats existing data.
+func (ns *nodeServer) NodeStageVolume(ctx context.Context, req *csi.NodeStageVolumeRequest) (*csi.NodeStageVolumeResponse, error) {
+	devicePath := req.GetPublishContext()["devicePath"]
+	if devicePath == "" {
+		return nil, status.Error(codes.InvalidArgument, "publish_context devicePath is required")
+	}
+	stagingPath := req.GetStagingTargetPath()
+	if stagingPath == "" {
+		return nil, status.Error(codes.InvalidArgument, "staging_target_path is required")
+	}
+
+	fsType := "ext4"
+	if mnt := req.GetVolumeCapability().GetMount(); mnt != nil && mnt.GetFsType() != "" {
+		fsType = mnt.GetFsType()
+	}
+
+	if err := os.MkdirAll(stagingPath, 0750); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	existingFSType, err := deviceFilesystemType(devicePath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to probe %q for an existing filesystem: %v", devicePath, err)
+	}
+	if existingFSType == "" {
+		if err := exec.Command("mkfs."+fsType, devicePath).Run(); err != nil {
+			return nil, status.Errorf(codes.Internal, "mkfs.%s failed: %v", fsType, err)
+		}
+	} else {
+		ns.logger.With("devicePath", devicePath, "fsType", existingFSType).Debug("Device is already formatted; skipping mkfs")
+	}
+
+	mounted, err := isMounted(stagingPath)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to check whether %q is already mounted: %v", stagingPath, err)
+	}
+	if !mounted {
+		if err := exec.Command("mount", "-t", fsType, devicePath, stagingPath).Run(); err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	return &csi.NodeStageVolumeResponse{}, nil
+}
+
+func (ns *nodeServer) NodeUnstageVolume(ctx context.Context, req *csi.NodeUnstageVolumeRequest) (*csi.NodeUnstageVolumeResponse, error) {
+	if err := exec.Command("umount", req.GetStagingTargetPath()).Run(); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &csi.NodeUnstageVolumeResponse{}, nil
+}
+
+// NodePublishVolume bind-mounts the staged volume into the target path the
+// kubelet expects the container's volume to appear at.
+func (ns *nodeServer) NodePublishVolume(ctx context.Context, req *csi.NodePublishVolumeRequest) (*csi.NodePublishVolumeResponse, error) {
+	targetPath := req.GetTargetPath()
+	if targetPath == "" {
+		return nil, status.Error(codes.InvalidArgument, "target_path is required")
+	}
+	if err := os.MkdirAll(targetPath, 0750); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if err := exec.Command("mount", "--bind", req.GetStagingTargetPath(), targetPath).Run(); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &csi.NodePublishVolumeResponse{}, nil
+}
+
+func (ns *nodeServer) NodeUnpublishVolume(ctx context.Context, req *csi.NodeUnpublishVolumeRequest) (*csi.NodeUnpublishVolumeResponse, error) {
+	if err := exec.Command("umount", req.GetTargetPath()).Run(); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &csi.NodeUnpublishVolumeResponse{}, nil
+}
+
+func (ns *nodeServer) NodeGetVolumeStats(ctx context.Context, req *csi.NodeGetVolumeStatsRequest) (*csi.NodeGetVolumeStatsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+func (ns *nodeServer) NodeExpandVolume(ctx context.Context, req *csi.NodeExpandVolumeRequest) (*csi.NodeExpandVolumeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "")
+}
+
+func (ns *nodeServer) NodeGetCapabilities(ctx context.Context, req *csi.NodeGetCapabilitiesRequest) (*csi.NodeGetCapabilitiesResponse, error) {
+	return &csi.NodeGetCapabilitiesResponse{
+		Capabilities: []*csi.NodeServiceCapability{
+			{
+				Type: &csi.NodeServiceCapability_Rpc{
+					Rpc: &csi.NodeServiceCapability_RPC{Type: csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME},
+				},
+			},
+		},
+	}, nil
+}
+
+// NodeGetInfo reports the node's availability domain so the external
+// provisioner sidecar can set topology.kubernetes.io/zone on volumes it
+// schedules to this node, the same zone block.Provision already labels
+// FlexVolume PVs with.
+func (ns *nodeServer) NodeGetInfo(ctx context.Context, req *csi.NodeGetInfoRequest) (*csi.NodeGetInfoResponse, error) {
+	nodeID, err := os.Hostname()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	metadata, err := ns.metadata.Get()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &csi.NodeGetInfoResponse{
+		NodeId: nodeID,
+		AccessibleTopology: &csi.Topology{
+			Segments: map[string]string{
+				block.TopologyZoneKey: metadata.AD,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kms resolves and validates OCI Vault keys used to encrypt
+// provisioned block volumes with customer-managed keys.
+package kms
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/oracle/oci-go-sdk/keymanagement"
+
+	"go.uber.org/zap"
+)
+
+// KeyManagementClient is the subset of the OCI KMS management client the
+// provisioner needs, so it can be faked in tests.
+type KeyManagementClient interface {
+	GetKey(ctx context.Context, request keymanagement.GetKeyRequest) (keymanagement.GetKeyResponse, error)
+}
+
+// ResolvedKey describes a validated customer-managed key, ready to be
+// passed through to core.CreateVolumeDetails.KmsKeyId.
+type ResolvedKey struct {
+	ID      string
+	Version string
+}
+
+// Resolve fetches keyID from the Vault management API, confirms it exists
+// and is enabled in the caller's tenancy, and logs the key version that
+// will protect the volume.
+func Resolve(ctx context.Context, client KeyManagementClient, logger *zap.SugaredLogger, keyID string) (*ResolvedKey, error) {
+	resp, err := client.GetKey(ctx, keymanagement.GetKeyRequest{KeyId: &keyID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up KMS key %q: %v", keyID, err)
+	}
+
+	if resp.LifecycleState != keymanagement.KeyLifecycleStateEnabled {
+		return nil, fmt.Errorf("KMS key %q is not enabled (state %q)", keyID, resp.LifecycleState)
+	}
+
+	version := ""
+	if resp.CurrentKeyVersion != nil {
+		version = *resp.CurrentKeyVersion
+	}
+
+	logger.With("kmsKeyID", keyID, "kmsKeyVersion", version).Info("Encrypting volume with customer-managed key")
+
+	return &ResolvedKey{ID: keyID, Version: version}, nil
+}
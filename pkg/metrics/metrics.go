@@ -0,0 +1,85 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics exposes Prometheus metrics for the provisioner: counters
+// for Provision/Delete outcomes, a latency histogram for the OCI volume
+// lifecycle, and per-PV filesystem capacity/usage/inode gauges.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"go.uber.org/zap"
+)
+
+var (
+	// ProvisionTotal counts successful volume provisions.
+	ProvisionTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "oci_provision_total",
+		Help: "Total number of volumes successfully provisioned.",
+	})
+	// ProvisionErrorsTotal counts failed volume provisions.
+	ProvisionErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "oci_provision_errors_total",
+		Help: "Total number of volume provisioning failures.",
+	})
+	// ProvisionDurationSeconds is the end-to-end latency of Provision calls.
+	ProvisionDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "oci_provision_duration_seconds",
+		Help:    "Time taken to provision a volume, in seconds.",
+		Buckets: prometheus.DefBuckets,
+	})
+	// DeleteTotal counts successful volume deletions.
+	DeleteTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "oci_delete_total",
+		Help: "Total number of volumes successfully deleted.",
+	})
+	// DeleteErrorsTotal counts failed volume deletions.
+	DeleteErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "oci_delete_errors_total",
+		Help: "Total number of volume deletion failures.",
+	})
+	// VolumeAvailableDurationSeconds is how long waitForVolumeAvailable
+	// took, bucketed by the lifecycle state the volume settled in, or
+	// PROVISIONING if the wait ended before a settled state was observed.
+	VolumeAvailableDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "oci_volume_available_duration_seconds",
+		Help:    "Time spent polling for a volume to leave the PROVISIONING state, labeled by the terminal lifecycle state observed (PROVISIONING if none was observed).",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"lifecycle_state"})
+)
+
+func init() {
+	prometheus.MustRegister(
+		ProvisionTotal,
+		ProvisionErrorsTotal,
+		ProvisionDurationSeconds,
+		DeleteTotal,
+		DeleteErrorsTotal,
+		VolumeAvailableDurationSeconds,
+	)
+}
+
+// Serve starts a Prometheus /metrics endpoint on addr and blocks until it
+// exits.
+func Serve(logger *zap.SugaredLogger, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	logger.With("address", addr).Info("Serving Prometheus metrics")
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+package metrics
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MountedVolume is a provisioned PV whose backing filesystem is mounted on
+// this node, as reported by MountedVolumesFunc.
+type MountedVolume struct {
+	PersistentVolumeName string
+	MountPath            string
+}
+
+// MountedVolumesFunc returns the set of PVs currently mounted on this node,
+// typically backed by the kubelet's volume manager.
+type MountedVolumesFunc func() ([]MountedVolume, error)
+
+// Reporter periodically statfs(2)s every mounted, provisioned PV and
+// records its capacity/usage/inode metrics.
+type Reporter struct {
+	interval       time.Duration
+	mountedVolumes MountedVolumesFunc
+	logger         *zap.SugaredLogger
+}
+
+// NewReporter creates a Reporter that polls mountedVolumes every interval.
+func NewReporter(logger *zap.SugaredLogger, interval time.Duration, mountedVolumes MountedVolumesFunc) *Reporter {
+	return &Reporter{interval: interval, mountedVolumes: mountedVolumes, logger: logger}
+}
+
+// Run polls and reports metrics until stopCh is closed.
+func (r *Reporter) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.reportOnce()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (r *Reporter) reportOnce() {
+	volumes, err := r.mountedVolumes()
+	if err != nil {
+		r.logger.With(zap.Error(err)).Warn("Unable to list mounted volumes for filesystem metrics")
+		return
+	}
+
+	for _, volume := range volumes {
+		if err := ReportPVFilesystemStats(volume.PersistentVolumeName, volume.MountPath); err != nil {
+			r.logger.With(zap.Error(err), "persistentVolume", volume.PersistentVolumeName).Warn("Unable to statfs mounted volume")
+		}
+	}
+}
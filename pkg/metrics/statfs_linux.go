@@ -0,0 +1,90 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build linux
+
+package metrics
+
+import (
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	volumeCapacityBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oci_volume_capacity_bytes",
+		Help: "Total capacity of the filesystem backing a provisioned PV, in bytes.",
+	}, []string{"persistentvolume"})
+	volumeUsedBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oci_volume_used_bytes",
+		Help: "Bytes used on the filesystem backing a provisioned PV.",
+	}, []string{"persistentvolume"})
+	volumeInodesTotal = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oci_volume_inodes_total",
+		Help: "Total inodes available on the filesystem backing a provisioned PV.",
+	}, []string{"persistentvolume"})
+	volumeInodesUsed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "oci_volume_inodes_used",
+		Help: "Inodes used on the filesystem backing a provisioned PV.",
+	}, []string{"persistentvolume"})
+)
+
+func init() {
+	prometheus.MustRegister(volumeCapacityBytes, volumeUsedBytes, volumeInodesTotal, volumeInodesUsed)
+}
+
+// FSStats is the statfs(2)-derived capacity/usage/inode snapshot for one
+// mounted filesystem, modeled on Kubernetes' metrics_statfs.go.
+type FSStats struct {
+	CapacityBytes int64
+	UsedBytes     int64
+	InodesTotal   int64
+	InodesUsed    int64
+}
+
+// Statfs runs statfs(2) against the filesystem mounted at path.
+func Statfs(path string) (FSStats, error) {
+	var buf syscall.Statfs_t
+	if err := syscall.Statfs(path, &buf); err != nil {
+		return FSStats{}, err
+	}
+
+	capacityBytes := int64(buf.Blocks) * int64(buf.Bsize)
+	availableBytes := int64(buf.Bavail) * int64(buf.Bsize)
+
+	return FSStats{
+		CapacityBytes: capacityBytes,
+		UsedBytes:     capacityBytes - availableBytes,
+		InodesTotal:   int64(buf.Files),
+		InodesUsed:    int64(buf.Files) - int64(buf.Ffree),
+	}, nil
+}
+
+// ReportPVFilesystemStats runs Statfs against mountPath and records the
+// result under the PV's name, for scraping by the Prometheus /metrics
+// endpoint Serve exposes.
+func ReportPVFilesystemStats(pvName, mountPath string) error {
+	stats, err := Statfs(mountPath)
+	if err != nil {
+		return err
+	}
+
+	volumeCapacityBytes.WithLabelValues(pvName).Set(float64(stats.CapacityBytes))
+	volumeUsedBytes.WithLabelValues(pvName).Set(float64(stats.UsedBytes))
+	volumeInodesTotal.WithLabelValues(pvName).Set(float64(stats.InodesTotal))
+	volumeInodesUsed.WithLabelValues(pvName).Set(float64(stats.InodesUsed))
+
+	return nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client wraps the OCI SDK clients the provisioner talks to behind
+// a single interface, so pkg/provisioner/block can be tested against a fake
+// without depending on the SDK's own client constructors.
+package client
+
+import (
+	"time"
+
+	"github.com/oracle/oci-go-sdk/common"
+	"github.com/oracle/oci-go-sdk/core"
+	"github.com/oracle/oci-go-sdk/keymanagement"
+
+	"github.com/oracle/oci-volume-provisioner/pkg/kms"
+)
+
+// ProvisionerClient is the set of OCI API clients and account identifiers
+// the block volume provisioner needs.
+type ProvisionerClient interface {
+	// BlockStorage is the client used to create, delete, resize and poll
+	// OCI block volumes.
+	BlockStorage() core.BlockstorageClient
+	// KeyManagement is the client used to resolve the KMS vault key a
+	// volume should be encrypted with.
+	KeyManagement() kms.KeyManagementClient
+	// CompartmentOCID is the compartment new volumes are created in.
+	CompartmentOCID() string
+	// TenancyOCID is the tenancy the provisioner is running in.
+	TenancyOCID() string
+	// Timeout bounds every OCI API call the provisioner makes.
+	Timeout() time.Duration
+}
+
+type client struct {
+	blockStorage    core.BlockstorageClient
+	keyManagement   keymanagement.KeyManagementClient
+	compartmentOCID string
+	tenancyOCID     string
+	timeout         time.Duration
+}
+
+// New builds a ProvisionerClient from configProvider, scoped to compartmentOCID.
+func New(configProvider common.ConfigurationProvider, compartmentOCID string, timeout time.Duration) (ProvisionerClient, error) {
+	blockStorage, err := core.NewBlockstorageClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return nil, err
+	}
+	keyManagement, err := keymanagement.NewKeyManagementClientWithConfigurationProvider(configProvider)
+	if err != nil {
+		return nil, err
+	}
+	tenancyOCID, err := configProvider.TenancyOCID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &client{
+		blockStorage:    blockStorage,
+		keyManagement:   keyManagement,
+		compartmentOCID: compartmentOCID,
+		tenancyOCID:     tenancyOCID,
+		timeout:         timeout,
+	}, nil
+}
+
+func (c *client) BlockStorage() core.BlockstorageClient { return c.blockStorage }
+
+func (c *client) KeyManagement() kms.KeyManagementClient { return c.keyManagement }
+
+func (c *client) CompartmentOCID() string { return c.compartmentOCID }
+
+func (c *client) TenancyOCID() string { return c.tenancyOCID }
+
+func (c *client) Timeout() time.Duration { return c.timeout }
@@ -0,0 +1,134 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retry classifies OCI SDK errors and applies exponential backoff
+// with jitter, shared by Provision/Delete and waitForVolumeAvailable so
+// that provisioning behaves consistently under API throttling.
+package retry
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/common"
+)
+
+// Policy bounds how many times, and how long, to retry a retryable OCI
+// operation.
+type Policy struct {
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultPolicy is a conservative backoff, with a generous ceiling, used
+// wherever a caller doesn't configure the --max-retries/--initial-backoff/
+// --max-backoff flags. It does not govern how often waitForVolumeAvailable
+// polls GetVolume; that has its own, separate poll interval.
+var DefaultPolicy = Policy{
+	MaxRetries:     8,
+	InitialBackoff: time.Second,
+	MaxBackoff:     time.Second * 30,
+}
+
+// NewPolicy builds a Policy from the --max-retries/--initial-backoff/--max-backoff
+// flags.
+func NewPolicy(maxRetries int, initialBackoff, maxBackoff time.Duration) Policy {
+	return Policy{MaxRetries: maxRetries, InitialBackoff: initialBackoff, MaxBackoff: maxBackoff}
+}
+
+// IsRetryable reports whether err is a transient OCI error: API throttling
+// (429), a 5xx service error, or a network-level failure.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if serviceErr, ok := common.IsServiceError(err); ok {
+		code := serviceErr.GetHTTPStatusCode()
+		return code == 429 || code >= 500
+	}
+
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+
+	return false
+}
+
+// backoff returns the delay before retry attempt n (0-indexed), exponential
+// in InitialBackoff capped at MaxBackoff, with up to 50% jitter to avoid
+// synchronized retries across many provisioners hitting the same API.
+func (p Policy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff << uint(attempt)
+	if delay <= 0 || delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	return delay/2 + jitter
+}
+
+// Do runs fn, retrying while IsRetryable(err) is true and fewer than
+// MaxRetries retries have been made (so fn runs at most MaxRetries+1 times),
+// honoring ctx cancellation. fn returns
+// the raw HTTP response alongside any error (nil if the call didn't reach
+// the point of getting one) so Do can honor a server-specified Retry-After
+// header, when present, instead of falling back to its own backoff.
+func (p Policy) Do(ctx context.Context, operation string, fn func() (*http.Response, error)) error {
+	var err error
+	for attempt := 0; attempt <= p.MaxRetries; attempt++ {
+		var resp *http.Response
+		resp, err = fn()
+		if err == nil {
+			return nil
+		}
+		if !IsRetryable(err) || attempt == p.MaxRetries {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		if retryAfter, ok := retryAfterDelay(resp); ok {
+			delay = retryAfter
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// retryAfterDelay extracts a server-specified Retry-After delay from resp,
+// if present. OCI sends this as a count of seconds (e.g. "2") when
+// throttling with a 429, not an HTTP-date.
+func retryAfterDelay(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
@@ -29,8 +29,11 @@ import (
 	"github.com/oracle/oci-go-sdk/core"
 	"github.com/oracle/oci-go-sdk/identity"
 
+	"github.com/oracle/oci-volume-provisioner/pkg/kms"
+	"github.com/oracle/oci-volume-provisioner/pkg/metrics"
 	"github.com/oracle/oci-volume-provisioner/pkg/oci/client"
 	"github.com/oracle/oci-volume-provisioner/pkg/oci/instancemeta"
+	"github.com/oracle/oci-volume-provisioner/pkg/oci/retry"
 	"github.com/oracle/oci-volume-provisioner/pkg/provisioner"
 	"github.com/oracle/oci-volume-provisioner/pkg/provisioner/plugin"
 
@@ -50,6 +53,40 @@ const (
 	// FSType is the name of the file storage type parameter for storage classes.
 	FSType                  = "fsType"
 	volumeRoundingUpEnabled = "volumeRoundingUpEnabled"
+	// volumeAvailablePollInterval is how often waitForVolumeAvailable polls
+	// GetVolume while provisioning, independent of retryPolicy's backoff
+	// (which governs retries of a single failed call, not this steady-state
+	// poll).
+	volumeAvailablePollInterval = 5 * time.Second
+	// AllowVolumeExpansion is the name of the storage class parameter that
+	// gates whether ExpandVolume will resize the underlying OCI volume.
+	AllowVolumeExpansion = "allowVolumeExpansion"
+	// KMSKeyID is the name of the storage class parameter (and, as an
+	// override, the PVC annotation) carrying the OCI Vault key OCID used to
+	// encrypt the volume at rest.
+	KMSKeyID = "kmsKeyId"
+	// OCIKMSKeyID is the name of the PV annotation recording the KMS key
+	// OCID a volume was actually encrypted with, for audit and DR purposes.
+	OCIKMSKeyID = "volume.beta.kubernetes.io/oci-kms-key-id"
+	// TopologyZoneKey is the well-known CSI/node topology label an OCI
+	// availability domain is published under.
+	TopologyZoneKey = "topology.kubernetes.io/zone"
+	// CSIDriverName is the CSI driver name Provision uses as
+	// PersistentVolumeSource.CSI.Driver in DriverModeCSI, and that the CSI
+	// Identity service reports as its own name.
+	CSIDriverName = "blockvolume.csi.oraclecloud.com"
+)
+
+// DriverMode selects which PersistentVolumeSource Provision emits:
+// FlexVolume (the long-standing default) or CSI.
+type DriverMode string
+
+const (
+	// DriverModeFlex makes Provision emit PersistentVolumeSource.FlexVolume.
+	DriverModeFlex DriverMode = "flex"
+	// DriverModeCSI makes Provision emit PersistentVolumeSource.CSI, with
+	// the OCI volume OCID as VolumeHandle.
+	DriverModeCSI DriverMode = "csi"
 )
 
 // blockProvisioner is the internal provisioner for OCI block volumes
@@ -59,6 +96,8 @@ type blockProvisioner struct {
 	volumeRoundingEnabled bool
 	minVolumeSize         resource.Quantity
 	timeout               time.Duration
+	retryPolicy           retry.Policy
+	driverMode            DriverMode
 	logger                *zap.SugaredLogger
 }
 
@@ -70,6 +109,8 @@ func NewBlockProvisioner(logger *zap.SugaredLogger, client client.ProvisionerCli
 	volumeRoundingEnabled bool,
 	minVolumeSize resource.Quantity,
 	timeout time.Duration,
+	retryPolicy retry.Policy,
+	driverMode DriverMode,
 ) plugin.ProvisionerPlugin {
 	return &blockProvisioner{
 		client:                client,
@@ -77,6 +118,8 @@ func NewBlockProvisioner(logger *zap.SugaredLogger, client client.ProvisionerCli
 		volumeRoundingEnabled: volumeRoundingEnabled,
 		minVolumeSize:         minVolumeSize,
 		timeout:               timeout,
+		retryPolicy:           retryPolicy,
+		driverMode:            driverMode,
 		logger: logger.With(
 			"compartmentID", client.CompartmentOCID(),
 			"tenancyID", client.TenancyOCID(),
@@ -101,6 +144,9 @@ func roundUpSize(volumeSizeBytes int64, allocationUnitBytes int64) int64 {
 }
 
 func (block *blockProvisioner) waitForVolumeAvailable(ctx context.Context, volumeID *string, timeout time.Duration) error {
+	start := time.Now()
+	lifecycleState := string(core.VolumeLifecycleStateProvisioning)
+
 	isVolumeReady := func() (bool, error) {
 		ctx, cancel := context.WithTimeout(ctx, block.client.Timeout())
 		defer cancel()
@@ -113,23 +159,33 @@ func (block *blockProvisioner) waitForVolumeAvailable(ctx context.Context, volum
 
 		switch state := getVolumeResponse.LifecycleState; state {
 		case core.VolumeLifecycleStateAvailable:
+			lifecycleState = string(state)
 			return true, nil
 		case core.VolumeLifecycleStateFaulty,
 			core.VolumeLifecycleStateTerminated,
 			core.VolumeLifecycleStateTerminating:
+			lifecycleState = string(state)
 			return false, fmt.Errorf("volume has lifecycle state %q", state)
 		}
 		return false, nil
 	}
 
-	return wait.PollImmediate(time.Second*5, timeout, func() (bool, error) {
+	deadline, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := wait.PollImmediateUntil(volumeAvailablePollInterval, func() (bool, error) {
 		ready, err := isVolumeReady()
 		if err != nil {
+			if retry.IsRetryable(err) {
+				return false, nil
+			}
 			return false, fmt.Errorf("failed to provision volume %q: %v", *volumeID, err)
 		}
 		return ready, nil
-	})
+	}, deadline.Done())
 
+	metrics.VolumeAvailableDurationSeconds.WithLabelValues(lifecycleState).Observe(time.Since(start).Seconds())
+	return err
 }
 
 func volumeRoundingEnabled(param map[string]string) bool {
@@ -142,8 +198,98 @@ func volumeRoundingEnabled(param map[string]string) bool {
 	return volumeRounding
 }
 
+// resolveKMSKeyID returns the OCI Vault key OCID a volume should be
+// encrypted with, if any. A per-PVC annotation takes precedence over the
+// storage class parameter, letting individual claims override the class
+// default.
+func resolveKMSKeyID(options controller.VolumeOptions) (string, bool) {
+	if keyID, ok := options.PVC.Annotations[KMSKeyID]; ok && keyID != "" {
+		return keyID, true
+	}
+	if keyID, ok := options.Parameters[KMSKeyID]; ok && keyID != "" {
+		return keyID, true
+	}
+	return "", false
+}
+
+func volumeExpansionAllowed(param map[string]string) bool {
+	if allowed, ok := param[AllowVolumeExpansion]; ok {
+		if enabled, err := strconv.ParseBool(allowed); err == nil {
+			return enabled
+		}
+	}
+	return false
+}
+
+// selectedNodeZone returns the availability domain the given node lives in,
+// read off the zone label the node's kubelet was started with.
+func selectedNodeZone(node *v1.Node) (string, bool) {
+	if zone, ok := node.Labels[TopologyZoneKey]; ok && zone != "" {
+		return zone, true
+	}
+	if zone, ok := node.Labels[plugin.LabelZoneFailureDomain]; ok && zone != "" {
+		return zone, true
+	}
+	return "", false
+}
+
+// validateAllowedTopologies rejects a zone the storage class's
+// allowedTopologies don't permit. An empty allowedTopologies imposes no
+// restriction.
+func validateAllowedTopologies(allowedTopologies []v1.TopologySelectorTerm, zone string) error {
+	if len(allowedTopologies) == 0 {
+		return nil
+	}
+	for _, term := range allowedTopologies {
+		for _, expression := range term.MatchLabelExpressions {
+			if expression.Key != TopologyZoneKey && expression.Key != plugin.LabelZoneFailureDomain {
+				continue
+			}
+			for _, value := range expression.Values {
+				if value == zone {
+					return nil
+				}
+			}
+		}
+	}
+	return fmt.Errorf("availability domain %q is not permitted by the storage class's allowedTopologies", zone)
+}
+
+// volumeNodeAffinity constrains scheduling to nodes in the zone a volume
+// was provisioned in, accepting either the current or legacy zone label so
+// that existing node pools using failure-domain.beta.kubernetes.io/zone
+// keep working.
+func volumeNodeAffinity(zone string) *v1.VolumeNodeAffinity {
+	return &v1.VolumeNodeAffinity{
+		Required: &v1.NodeSelector{
+			NodeSelectorTerms: []v1.NodeSelectorTerm{
+				{
+					MatchExpressions: []v1.NodeSelectorRequirement{
+						{Key: TopologyZoneKey, Operator: v1.NodeSelectorOpIn, Values: []string{zone}},
+					},
+				},
+				{
+					MatchExpressions: []v1.NodeSelectorRequirement{
+						{Key: plugin.LabelZoneFailureDomain, Operator: v1.NodeSelectorOpIn, Values: []string{zone}},
+					},
+				},
+			},
+		},
+	}
+}
+
 // Provision creates an OCI block volume
-func (block *blockProvisioner) Provision(options controller.VolumeOptions, ad *identity.AvailabilityDomain) (*v1.PersistentVolume, error) {
+func (block *blockProvisioner) Provision(options controller.VolumeOptions, ad *identity.AvailabilityDomain) (pv *v1.PersistentVolume, err error) {
+	start := time.Now()
+	defer func() {
+		metrics.ProvisionDurationSeconds.Observe(time.Since(start).Seconds())
+		if err != nil {
+			metrics.ProvisionErrorsTotal.Inc()
+			return
+		}
+		metrics.ProvisionTotal.Inc()
+	}()
+
 	ctx := context.Background()
 	for _, accessMode := range options.PVC.Spec.AccessModes {
 		if accessMode != v1.ReadWriteOnce {
@@ -151,6 +297,20 @@ func (block *blockProvisioner) Provision(options controller.VolumeOptions, ad *i
 		}
 	}
 
+	// With volumeBindingMode: WaitForFirstConsumer the scheduler has already
+	// picked a node for the pod using the volume, so provision in that
+	// node's availability domain rather than the one selected externally.
+	if options.SelectedNode != nil {
+		zone, ok := selectedNodeZone(options.SelectedNode)
+		if !ok {
+			return nil, fmt.Errorf("selected node %q has no %q or %q label", options.SelectedNode.Name, TopologyZoneKey, plugin.LabelZoneFailureDomain)
+		}
+		if err := validateAllowedTopologies(options.AllowedTopologies, zone); err != nil {
+			return nil, err
+		}
+		ad = &identity.AvailabilityDomain{Name: &zone}
+	}
+
 	// Calculate the volume size
 	capacity, ok := options.PVC.Spec.Resources.Requests[v1.ResourceName(v1.ResourceStorage)]
 	if !ok {
@@ -186,11 +346,26 @@ func (block *blockProvisioner) Provision(options controller.VolumeOptions, ad *i
 		volumeDetails.SourceDetails = &core.VolumeSourceFromVolumeBackupDetails{Id: &value}
 	}
 
+	var resolvedKey *kms.ResolvedKey
+	if keyID, ok := resolveKMSKeyID(options); ok {
+		resolved, err := kms.Resolve(ctx, block.client.KeyManagement(), logger, keyID)
+		if err != nil {
+			return nil, err
+		}
+		resolvedKey = resolved
+		volumeDetails.KmsKeyId = common.String(resolvedKey.ID)
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, block.client.Timeout())
 	defer cancel()
 
-	newVolume, err := block.client.BlockStorage().CreateVolume(ctx, core.CreateVolumeRequest{
-		CreateVolumeDetails: volumeDetails,
+	var newVolume core.Volume
+	err = block.retryPolicy.Do(ctx, "CreateVolume", func() (*http.Response, error) {
+		response, err := block.client.BlockStorage().CreateVolume(ctx, core.CreateVolumeRequest{
+			CreateVolumeDetails: volumeDetails,
+		})
+		newVolume = response.Volume
+		return response.RawResponse, err
 	})
 	if err != nil {
 		return nil, err
@@ -219,7 +394,25 @@ func (block *blockProvisioner) Provision(options controller.VolumeOptions, ad *i
 		region = metadata.Region
 	}
 
-	pv := &v1.PersistentVolume{
+	var volumeSource v1.PersistentVolumeSource
+	if block.driverMode == DriverModeCSI {
+		volumeSource = v1.PersistentVolumeSource{
+			CSI: &v1.CSIPersistentVolumeSource{
+				Driver:       CSIDriverName,
+				VolumeHandle: *newVolume.Id,
+				FSType:       filesystemType,
+			},
+		}
+	} else {
+		volumeSource = v1.PersistentVolumeSource{
+			FlexVolume: &v1.FlexPersistentVolumeSource{
+				Driver: plugin.OCIProvisionerName,
+				FSType: filesystemType,
+			},
+		}
+	}
+
+	pv = &v1.PersistentVolume{
 		ObjectMeta: metav1.ObjectMeta{
 			Name: *newVolume.Id,
 			Annotations: map[string]string{
@@ -228,6 +421,7 @@ func (block *blockProvisioner) Provision(options controller.VolumeOptions, ad *i
 			Labels: map[string]string{
 				plugin.LabelZoneRegion:        region,
 				plugin.LabelZoneFailureDomain: *ad.Name,
+				TopologyZoneKey:               *ad.Name,
 			},
 		},
 		Spec: v1.PersistentVolumeSpec{
@@ -236,21 +430,29 @@ func (block *blockProvisioner) Provision(options controller.VolumeOptions, ad *i
 			Capacity: v1.ResourceList{
 				v1.ResourceName(v1.ResourceStorage): capacity,
 			},
-			PersistentVolumeSource: v1.PersistentVolumeSource{
-				FlexVolume: &v1.FlexPersistentVolumeSource{
-					Driver: plugin.OCIProvisionerName,
-					FSType: filesystemType,
-				},
-			},
-			MountOptions: options.MountOptions,
+			PersistentVolumeSource: volumeSource,
+			MountOptions:           options.MountOptions,
+			NodeAffinity:           volumeNodeAffinity(*ad.Name),
 		},
 	}
 
+	if resolvedKey != nil {
+		pv.Annotations[OCIKMSKeyID] = resolvedKey.ID
+	}
+
 	return pv, nil
 }
 
 // Delete destroys a OCI volume created by Provision
-func (block *blockProvisioner) Delete(volume *v1.PersistentVolume) error {
+func (block *blockProvisioner) Delete(volume *v1.PersistentVolume) (err error) {
+	defer func() {
+		if err != nil {
+			metrics.DeleteErrorsTotal.Inc()
+			return
+		}
+		metrics.DeleteTotal.Inc()
+	}()
+
 	ctx := context.Background()
 	volID, ok := volume.Annotations[OCIVolumeID]
 	if !ok {
@@ -265,7 +467,12 @@ func (block *blockProvisioner) Delete(volume *v1.PersistentVolume) error {
 	ctx, cancel := context.WithTimeout(ctx, block.client.Timeout())
 	defer cancel()
 
-	response, err := block.client.BlockStorage().DeleteVolume(ctx, request)
+	var response core.DeleteVolumeResponse
+	err = block.retryPolicy.Do(ctx, "DeleteVolume", func() (*http.Response, error) {
+		var deleteErr error
+		response, deleteErr = block.client.BlockStorage().DeleteVolume(ctx, request)
+		return response.RawResponse, deleteErr
+	})
 	// If the volume does not exist (perhaps a user deleted it) then stop retrying the delete
 	// Note that we cannot differentiate between a volume that no longer exists and an authentication failure.
 	if response.RawResponse != nil && response.RawResponse.StatusCode == http.StatusNotFound {
@@ -278,3 +485,46 @@ func (block *blockProvisioner) Delete(volume *v1.PersistentVolume) error {
 
 	return err
 }
+
+// ExpandVolume grows a volume previously created by Provision to newSize,
+// returning the capacity OCI actually provisioned (which may be rounded up).
+// It is called when a bound PVC's spec.resources.requests.storage is
+// increased and the PVC's storage class has AllowVolumeExpansion set.
+func (block *blockProvisioner) ExpandVolume(volume *v1.PersistentVolume, newSize resource.Quantity, parameters map[string]string) (resource.Quantity, error) {
+	if !volumeExpansionAllowed(parameters) {
+		return resource.Quantity{}, fmt.Errorf("volume expansion is not enabled for this storage class; set %q to \"true\"", AllowVolumeExpansion)
+	}
+
+	volID, ok := volume.Annotations[OCIVolumeID]
+	if !ok {
+		return resource.Quantity{}, errors.New("volumeid annotation not found on PV")
+	}
+
+	volSizeGB := int(roundUpSize(newSize.Value(), 1024*1024*1024))
+
+	logger := block.logger.With("volumeOCID", volID, "newVolumeSizeGB", volSizeGB)
+	logger.Info("Expanding volume")
+
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, block.client.Timeout())
+	defer cancel()
+
+	err := block.retryPolicy.Do(ctx, "UpdateVolume", func() (*http.Response, error) {
+		response, err := block.client.BlockStorage().UpdateVolume(ctx, core.UpdateVolumeRequest{
+			VolumeId: common.String(volID),
+			UpdateVolumeDetails: core.UpdateVolumeDetails{
+				SizeInGBs: common.Int64(int64(volSizeGB)),
+			},
+		})
+		return response.RawResponse, err
+	})
+	if err != nil {
+		return resource.Quantity{}, err
+	}
+
+	if err := block.waitForVolumeAvailable(ctx, common.String(volID), block.timeout); err != nil {
+		return resource.Quantity{}, fmt.Errorf("failed waiting for expanded volume %q to become available: %v", volID, err)
+	}
+
+	return *resource.NewQuantity(int64(volSizeGB)*1024*1024*1024, resource.BinarySI), nil
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plugin defines the provisioner-agnostic interface and well-known
+// label/annotation names shared by the FlexVolume (pkg/provisioner/block)
+// and CSI (pkg/csi) code paths.
+package plugin
+
+import (
+	"github.com/kubernetes-incubator/external-storage/lib/controller"
+	"github.com/oracle/oci-go-sdk/identity"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// OCIProvisionerName is the name registered with the external-storage
+	// ProvisionController and used as PersistentVolumeSource.FlexVolume.Driver.
+	OCIProvisionerName = "oracle.com/oci"
+	// LabelZoneRegion is the well-known label Provision sets on every PV to
+	// the OCI region it was created in.
+	LabelZoneRegion = "failure-domain.beta.kubernetes.io/region"
+	// LabelZoneFailureDomain is the well-known label Provision sets on every
+	// PV to the OCI availability domain it was created in.
+	LabelZoneFailureDomain = "failure-domain.beta.kubernetes.io/zone"
+)
+
+// ProvisionerPlugin is the interface the FlexVolume and CSI code paths
+// provision, delete and expand OCI block volumes through, so that neither
+// has to know how the other represents a volume on the Kubernetes side.
+type ProvisionerPlugin interface {
+	// Provision creates a new OCI block volume in ad (or, when
+	// options.SelectedNode is set, in that node's availability domain) and
+	// returns the PV Kubernetes should bind to the requesting PVC.
+	Provision(options controller.VolumeOptions, ad *identity.AvailabilityDomain) (*v1.PersistentVolume, error)
+	// Delete destroys the OCI block volume backing volume.
+	Delete(volume *v1.PersistentVolume) error
+	// ExpandVolume grows the OCI block volume backing volume to newSize,
+	// returning the capacity OCI actually provisioned.
+	ExpandVolume(volume *v1.PersistentVolume, newSize resource.Quantity, parameters map[string]string) (resource.Quantity, error)
+}
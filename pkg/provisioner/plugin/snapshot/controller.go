@@ -0,0 +1,175 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"fmt"
+	"time"
+
+	crdv1 "github.com/kubernetes-incubator/external-storage/snapshot/pkg/apis/crd/v1"
+	snapshotclient "github.com/kubernetes-incubator/external-storage/snapshot/pkg/client/clientset/versioned"
+
+	"go.uber.org/zap"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ociBackupIDAnnotation records the OCI Block Volume Backup OCID a
+// VolumeSnapshotData was created from, the same way block.OCIVolumeID
+// records a PV's underlying OCI volume, since the vendored
+// crdv1.VolumeSnapshotDataSource has no generic slot for an out-of-tree
+// cloud provider's backup source.
+const ociBackupIDAnnotation = "snapshot.alpha.kubernetes.io/oci-backup-id"
+
+// Controller reconciles VolumeSnapshot objects against OCI Block Volume
+// Backups: it creates a backup and a corresponding VolumeSnapshotData for
+// every VolumeSnapshot that doesn't have one yet, and deletes the backup
+// behind a VolumeSnapshotData whose VolumeSnapshot has been removed.
+type Controller struct {
+	kubeClient     kubernetes.Interface
+	snapshotClient snapshotclient.Interface
+	snapshotter    *Snapshotter
+	resyncPeriod   time.Duration
+	logger         *zap.SugaredLogger
+}
+
+// NewController creates a Controller that reconciles every resyncPeriod.
+func NewController(logger *zap.SugaredLogger, kubeClient kubernetes.Interface, snapshotClient snapshotclient.Interface, snapshotter *Snapshotter, resyncPeriod time.Duration) *Controller {
+	return &Controller{
+		kubeClient:     kubeClient,
+		snapshotClient: snapshotClient,
+		snapshotter:    snapshotter,
+		resyncPeriod:   resyncPeriod,
+		logger:         logger,
+	}
+}
+
+// Run reconciles until stopCh is closed.
+func (c *Controller) Run(stopCh <-chan struct{}) {
+	wait.Until(c.reconcileOnce, c.resyncPeriod, stopCh)
+}
+
+func (c *Controller) reconcileOnce() {
+	c.createPendingBackups()
+	c.deleteOrphanedBackups()
+}
+
+// createPendingBackups backs up every VolumeSnapshot that has no
+// VolumeSnapshotData yet.
+func (c *Controller) createPendingBackups() {
+	snapshots, err := c.snapshotClient.VolumesnapshotV1().VolumeSnapshots(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		c.logger.With(zap.Error(err)).Warn("Unable to list VolumeSnapshots for backup reconciliation")
+		return
+	}
+
+	for i := range snapshots.Items {
+		snapshot := &snapshots.Items[i]
+		if snapshot.Spec.SnapshotDataName != "" {
+			continue
+		}
+		if err := c.createBackup(snapshot); err != nil {
+			c.logger.With(zap.Error(err), "snapshot", snapshot.Name).Warn("Failed to back up VolumeSnapshot")
+		}
+	}
+}
+
+func (c *Controller) createBackup(snapshot *crdv1.VolumeSnapshot) error {
+	pvc, err := c.kubeClient.CoreV1().PersistentVolumeClaims(snapshot.Namespace).Get(snapshot.Spec.PersistentVolumeClaimName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get PVC %q for VolumeSnapshot %q: %v", snapshot.Spec.PersistentVolumeClaimName, snapshot.Name, err)
+	}
+	pv, err := c.kubeClient.CoreV1().PersistentVolumes().Get(pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get PV %q for VolumeSnapshot %q: %v", pvc.Spec.VolumeName, snapshot.Name, err)
+	}
+
+	backupID, err := c.snapshotter.Create(pv, snapshot.Name, snapshot.Annotations)
+	if err != nil {
+		return err
+	}
+	if err := c.snapshotter.WaitForBackup(backupID); err != nil {
+		return fmt.Errorf("backup %q for VolumeSnapshot %q did not become available: %v", backupID, snapshot.Name, err)
+	}
+
+	data := &crdv1.VolumeSnapshotData{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s-%s", snapshot.Namespace, snapshot.Name),
+			Annotations: map[string]string{ociBackupIDAnnotation: backupID},
+		},
+		Spec: crdv1.VolumeSnapshotDataSpec{
+			VolumeSnapshotRef: &crdv1.CrossNamespaceObjectReference{
+				Name:      snapshot.Name,
+				Namespace: snapshot.Namespace,
+			},
+			PersistentVolumeRef: &crdv1.CrossNamespaceObjectReference{
+				Name: pv.Name,
+			},
+		},
+	}
+	if _, err := c.snapshotClient.VolumesnapshotV1().VolumeSnapshotDatas().Create(data); err != nil {
+		return fmt.Errorf("failed to create VolumeSnapshotData for backup %q: %v", backupID, err)
+	}
+
+	snapshot.Spec.SnapshotDataName = data.Name
+	if _, err := c.snapshotClient.VolumesnapshotV1().VolumeSnapshots(snapshot.Namespace).Update(snapshot); err != nil {
+		return fmt.Errorf("failed to set SnapshotDataName on VolumeSnapshot %q: %v", snapshot.Name, err)
+	}
+
+	c.logger.With("snapshot", snapshot.Name, "backupID", backupID).Info("Backed up VolumeSnapshot")
+	return nil
+}
+
+// deleteOrphanedBackups deletes the OCI Block Volume Backup behind every
+// VolumeSnapshotData whose VolumeSnapshot no longer exists.
+func (c *Controller) deleteOrphanedBackups() {
+	datas, err := c.snapshotClient.VolumesnapshotV1().VolumeSnapshotDatas().List(metav1.ListOptions{})
+	if err != nil {
+		c.logger.With(zap.Error(err)).Warn("Unable to list VolumeSnapshotDatas for backup deletion reconciliation")
+		return
+	}
+
+	for i := range datas.Items {
+		data := &datas.Items[i]
+		ref := data.Spec.VolumeSnapshotRef
+		if ref == nil {
+			continue
+		}
+
+		_, err := c.snapshotClient.VolumesnapshotV1().VolumeSnapshots(ref.Namespace).Get(ref.Name, metav1.GetOptions{})
+		if err == nil {
+			continue
+		}
+		if !apierrors.IsNotFound(err) {
+			c.logger.With(zap.Error(err), "volumeSnapshotData", data.Name).Warn("Unable to check whether VolumeSnapshot still exists")
+			continue
+		}
+
+		backupID, ok := data.Annotations[ociBackupIDAnnotation]
+		if !ok {
+			continue
+		}
+		if err := c.snapshotter.Delete(backupID); err != nil {
+			c.logger.With(zap.Error(err), "volumeSnapshotData", data.Name, "backupID", backupID).Warn("Failed to delete orphaned volume backup")
+			continue
+		}
+		if err := c.snapshotClient.VolumesnapshotV1().VolumeSnapshotDatas().Delete(data.Name, &metav1.DeleteOptions{}); err != nil {
+			c.logger.With(zap.Error(err), "volumeSnapshotData", data.Name).Warn("Failed to delete VolumeSnapshotData after deleting its backup")
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snapshot backs provisioned PVs up to, and restores them from, OCI
+// Block Volume Backups, complementing the block.OCIVolumeBackupID restore
+// path in block.Provision with the other half of the snapshot-and-restore
+// cycle. It does not implement external-storage's snapshot volume.Plugin
+// interface; it is a standalone helper a snapshot controller can call into.
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/oracle/oci-go-sdk/common"
+	"github.com/oracle/oci-go-sdk/core"
+
+	"go.uber.org/zap"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/oracle/oci-volume-provisioner/pkg/oci/client"
+	"github.com/oracle/oci-volume-provisioner/pkg/provisioner"
+	"github.com/oracle/oci-volume-provisioner/pkg/provisioner/block"
+)
+
+const (
+	// BackupType is the key in Create's parameters selecting the OCI
+	// backup type: "full" (the default) or "incremental". Controller passes
+	// a VolumeSnapshot's own annotations as those parameters; no
+	// VolumeSnapshotClass is read.
+	BackupType = "backupType"
+
+	backupTypeFull        = "full"
+	backupTypeIncremental = "incremental"
+
+	pollInterval = 5 * time.Second
+	pollTimeout  = 10 * time.Minute
+)
+
+// Snapshotter creates, polls, deletes and lists OCI Block Volume Backups for
+// PVs provisioned by block.blockProvisioner.
+type Snapshotter struct {
+	client client.ProvisionerClient
+	logger *zap.SugaredLogger
+}
+
+// NewSnapshotter creates a Snapshotter backed by client.
+func NewSnapshotter(logger *zap.SugaredLogger, client client.ProvisionerClient) *Snapshotter {
+	return &Snapshotter{client: client, logger: logger}
+}
+
+func resolveBackupType(parameters map[string]string) (core.CreateVolumeBackupDetailsTypeEnum, error) {
+	switch parameters[BackupType] {
+	case "", backupTypeFull:
+		return core.CreateVolumeBackupDetailsTypeFull, nil
+	case backupTypeIncremental:
+		return core.CreateVolumeBackupDetailsTypeIncremental, nil
+	default:
+		return "", fmt.Errorf("unknown %q parameter %q; expected %q or %q", BackupType, parameters[BackupType], backupTypeFull, backupTypeIncremental)
+	}
+}
+
+// Create backs up the volume underlying pv into a new OCI Block Volume
+// Backup named name, using the backup type named in parameters, and
+// returns the new backup's OCID.
+func (s *Snapshotter) Create(pv *v1.PersistentVolume, name string, parameters map[string]string) (string, error) {
+	volumeID, ok := pv.Annotations[block.OCIVolumeID]
+	if !ok {
+		return "", fmt.Errorf("PV %q has no %q annotation; it was not provisioned by this driver", pv.Name, block.OCIVolumeID)
+	}
+
+	backupType, err := resolveBackupType(parameters)
+	if err != nil {
+		return "", err
+	}
+
+	logger := s.logger.With("volumeID", volumeID, "snapshotName", name, "backupType", backupType)
+	logger.Info("Creating volume backup")
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.Timeout())
+	defer cancel()
+
+	backup, err := s.client.BlockStorage().CreateVolumeBackup(ctx, core.CreateVolumeBackupRequest{
+		CreateVolumeBackupDetails: core.CreateVolumeBackupDetails{
+			VolumeId:    common.String(volumeID),
+			DisplayName: common.String(fmt.Sprintf("%s%s", provisioner.GetPrefix(), name)),
+			Type:        backupType,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create volume backup: %v", err)
+	}
+
+	return *backup.Id, nil
+}
+
+// Describe reports whether the backup referenced by backupID has reached
+// the AVAILABLE lifecycle state.
+func (s *Snapshotter) Describe(backupID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.Timeout())
+	defer cancel()
+
+	resp, err := s.client.BlockStorage().GetVolumeBackup(ctx, core.GetVolumeBackupRequest{VolumeBackupId: &backupID})
+	if err != nil {
+		return false, err
+	}
+
+	switch resp.LifecycleState {
+	case core.VolumeBackupLifecycleStateAvailable:
+		return true, nil
+	case core.VolumeBackupLifecycleStateFaulty:
+		return false, fmt.Errorf("volume backup %q has lifecycle state %q", backupID, resp.LifecycleState)
+	}
+	return false, nil
+}
+
+// WaitForBackup blocks until Describe reports the backup ready, or
+// pollTimeout elapses.
+func (s *Snapshotter) WaitForBackup(backupID string) error {
+	return wait.PollImmediate(pollInterval, pollTimeout, func() (bool, error) {
+		return s.Describe(backupID)
+	})
+}
+
+// Delete removes the OCI Block Volume Backup referenced by backupID,
+// tolerating a backup that has already been deleted out of band, the same
+// way block.Delete tolerates a missing volume.
+func (s *Snapshotter) Delete(backupID string) error {
+	logger := s.logger.With("volumeBackupID", backupID)
+	logger.Info("Deleting volume backup")
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.Timeout())
+	defer cancel()
+
+	_, err := s.client.BlockStorage().DeleteVolumeBackup(ctx, core.DeleteVolumeBackupRequest{VolumeBackupId: &backupID})
+	if provisioner.IsNotFound(err) {
+		logger.With(zap.Error(err)).Info("VolumeBackupID was not found. Unable to delete it.")
+		return nil
+	}
+	return err
+}
+
+// List returns every volume backup in the compartment, for reconciling
+// against backups created out of band.
+func (s *Snapshotter) List(ctx context.Context) ([]core.VolumeBackupSummary, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.client.Timeout())
+	defer cancel()
+
+	var backups []core.VolumeBackupSummary
+	request := core.ListVolumeBackupsRequest{
+		CompartmentId: common.String(s.client.CompartmentOCID()),
+	}
+	for {
+		resp, err := s.client.BlockStorage().ListVolumeBackups(ctx, request)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list volume backups: %v", err)
+		}
+		backups = append(backups, resp.Items...)
+		if resp.OpcNextPage == nil {
+			return backups, nil
+		}
+		request.Page = resp.OpcNextPage
+	}
+}
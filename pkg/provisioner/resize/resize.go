@@ -0,0 +1,152 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resize reconciles bound PVCs whose requested storage has grown
+// past their PV's capacity: it calls plugin.ProvisionerPlugin.ExpandVolume
+// to resize the underlying OCI volume, patches the PV's capacity, and sets
+// the PVC's status capacity and FileSystemResizePending condition so that
+// kubelet's volume resize code path can finish growing the filesystem.
+package resize
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/oracle/oci-volume-provisioner/pkg/provisioner/block"
+	"github.com/oracle/oci-volume-provisioner/pkg/provisioner/plugin"
+)
+
+// Controller polls bound PVCs on a fixed period, looking for ones whose
+// requested storage exceeds their bound PV's capacity, and resizes them.
+type Controller struct {
+	client       kubernetes.Interface
+	plugin       plugin.ProvisionerPlugin
+	resyncPeriod time.Duration
+	logger       *zap.SugaredLogger
+}
+
+// NewController creates a Controller that reconciles every resyncPeriod.
+func NewController(logger *zap.SugaredLogger, client kubernetes.Interface, provisioner plugin.ProvisionerPlugin, resyncPeriod time.Duration) *Controller {
+	return &Controller{client: client, plugin: provisioner, resyncPeriod: resyncPeriod, logger: logger}
+}
+
+// Run reconciles until stopCh is closed.
+func (c *Controller) Run(stopCh <-chan struct{}) {
+	wait.Until(c.reconcileOnce, c.resyncPeriod, stopCh)
+}
+
+func (c *Controller) reconcileOnce() {
+	pvcs, err := c.client.CoreV1().PersistentVolumeClaims(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		c.logger.With(zap.Error(err)).Warn("Unable to list PVCs for resize reconciliation")
+		return
+	}
+
+	for i := range pvcs.Items {
+		pvc := &pvcs.Items[i]
+		if pvc.Status.Phase != v1.ClaimBound || pvc.Spec.VolumeName == "" {
+			continue
+		}
+		if err := c.reconcilePVC(pvc); err != nil {
+			c.logger.With(zap.Error(err), "pvc", pvc.Name, "pv", pvc.Spec.VolumeName).Warn("Failed to reconcile PVC resize")
+		}
+	}
+}
+
+func (c *Controller) reconcilePVC(pvc *v1.PersistentVolumeClaim) error {
+	requested, ok := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if !ok {
+		return nil
+	}
+
+	pv, err := c.client.CoreV1().PersistentVolumes().Get(pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	if pv.Annotations[block.OCIVolumeID] == "" {
+		return nil
+	}
+
+	capacity := pv.Spec.Capacity[v1.ResourceStorage]
+	if requested.Cmp(capacity) <= 0 {
+		return nil
+	}
+
+	var parameters map[string]string
+	if pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName != "" {
+		class, err := c.client.StorageV1().StorageClasses().Get(*pvc.Spec.StorageClassName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get storage class %q for PVC %q: %v", *pvc.Spec.StorageClassName, pvc.Name, err)
+		}
+		parameters = class.Parameters
+	}
+
+	logger := c.logger.With("pvc", pvc.Name, "pv", pv.Name)
+	logger.Info("PVC requests more storage than its PV has; expanding underlying volume")
+
+	newSize, err := c.plugin.ExpandVolume(pv, requested, parameters)
+	if err != nil {
+		return fmt.Errorf("failed to expand volume for PV %q: %v", pv.Name, err)
+	}
+
+	pv.Spec.Capacity[v1.ResourceStorage] = newSize
+	if _, err := c.client.CoreV1().PersistentVolumes().Update(pv); err != nil {
+		return fmt.Errorf("failed to update capacity on PV %q: %v", pv.Name, err)
+	}
+
+	if err := c.markFileSystemResizePending(pvc, newSize); err != nil {
+		return fmt.Errorf("failed to update PVC %q status after expanding PV %q: %v", pvc.Name, pv.Name, err)
+	}
+
+	logger.With("newSize", newSize.String()).Info("Expanded volume and updated PV capacity")
+	return nil
+}
+
+// markFileSystemResizePending records newSize as the PVC's status capacity
+// and sets the FileSystemResizePending condition, the same signal the
+// in-tree expand controller gives kubelet so it knows to grow the
+// filesystem on the node to match the now-larger block device.
+func (c *Controller) markFileSystemResizePending(pvc *v1.PersistentVolumeClaim, newSize resource.Quantity) error {
+	pvc = pvc.DeepCopy()
+	if pvc.Status.Capacity == nil {
+		pvc.Status.Capacity = v1.ResourceList{}
+	}
+	pvc.Status.Capacity[v1.ResourceStorage] = newSize
+
+	condition := v1.PersistentVolumeClaimCondition{
+		Type:               v1.PersistentVolumeClaimFileSystemResizePending,
+		Status:             v1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Message:            "Waiting for user to (re-)start a pod to finish file system resize of volume on node.",
+	}
+	for i, existing := range pvc.Status.Conditions {
+		if existing.Type == condition.Type {
+			pvc.Status.Conditions[i] = condition
+			_, err := c.client.CoreV1().PersistentVolumeClaims(pvc.Namespace).UpdateStatus(pvc)
+			return err
+		}
+	}
+	pvc.Status.Conditions = append(pvc.Status.Conditions, condition)
+
+	_, err := c.client.CoreV1().PersistentVolumeClaims(pvc.Namespace).UpdateStatus(pvc)
+	return err
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2018, Oracle and/or its affiliates. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"github.com/onsi/ginkgo"
+	"github.com/onsi/gomega"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"github.com/oracle/oci-volume-provisioner/pkg/provisioner/block"
+	"github.com/oracle/oci-volume-provisioner/test/e2e/framework"
+)
+
+var _ = ginkgo.Describe("Volume expansion", func() {
+	f := framework.NewDefaultFramework("volume-expansion")
+
+	ginkgo.It("should resize the underlying OCI volume when the PVC's requested storage grows", func() {
+		pvc := framework.NewPVCTemplate(f.Namespace.Name, "1Gi", map[string]string{
+			block.AllowVolumeExpansion: "true",
+		})
+
+		pvc, err := f.ClientSet.CoreV1().PersistentVolumeClaims(f.Namespace.Name).Create(pvc)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+		framework.ExpectNoError(framework.WaitForPersistentVolumeClaimPhase(v1.ClaimBound, f.ClientSet, f.Namespace.Name, pvc.Name, framework.Poll, framework.ClaimProvisionTimeout))
+
+		pvc, err = f.ClientSet.CoreV1().PersistentVolumeClaims(f.Namespace.Name).Get(pvc.Name, metav1.GetOptions{})
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		pvc.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse("2Gi")
+		_, err = f.ClientSet.CoreV1().PersistentVolumeClaims(f.Namespace.Name).Update(pvc)
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+
+		err = wait.PollImmediate(framework.Poll, framework.ClaimProvisionTimeout, func() (bool, error) {
+			pv, err := f.ClientSet.CoreV1().PersistentVolumes().Get(pvc.Spec.VolumeName, metav1.GetOptions{})
+			if err != nil {
+				return false, err
+			}
+			capacity := pv.Spec.Capacity[v1.ResourceStorage]
+			return capacity.Cmp(resource.MustParse("2Gi")) >= 0, nil
+		})
+		gomega.Expect(err).NotTo(gomega.HaveOccurred())
+	})
+})